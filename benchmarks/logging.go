@@ -0,0 +1,51 @@
+package benchmarks
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/treeverse/lakefs/logging"
+	"github.com/treeverse/lakefs/pkg/retry"
+)
+
+func init() {
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.format", "text")
+}
+
+// configureLogging applies the --log.level/--log.format viper settings to
+// the package logger.
+func configureLogging() {
+	logging.SetLevel(viper.GetString("log.level"))
+	logging.SetOutputFormat(viper.GetString("log.format"))
+}
+
+// requestLogger returns a per-request logger carrying the fields common to
+// every attempt against a single file: repository, operation and file number.
+func requestLogger(repoName, operation, file string) logging.Logger {
+	return logger.WithFields(logging.Fields{
+		"repository": repoName,
+		"operation":  operation,
+		"fileNum":    file,
+	})
+}
+
+// onRetry returns a retry.Config.OnRetry callback that logs each retry
+// attempt against reqLogger.
+func onRetry(reqLogger logging.Logger) func(attempt int, err error, backoff time.Duration) {
+	return func(attempt int, err error, backoff time.Duration) {
+		reqLogger.WithFields(errorFields(err, logging.Fields{
+			"attempt": attempt + 1,
+			"backoff": backoff,
+		})).WithError(err).Warn("Retrying failed request")
+	}
+}
+
+// errorFields adds a "status" field to fields when err carries an HTTP
+// status code (see retry.StatusCode), leaving fields unchanged otherwise.
+func errorFields(err error, fields logging.Fields) logging.Fields {
+	if code, ok := retry.StatusCode(err); ok {
+		fields["status"] = code
+	}
+	return fields
+}
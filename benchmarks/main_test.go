@@ -3,12 +3,9 @@ package benchmarks
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/go-openapi/runtime"
 	"github.com/go-openapi/swag"
-	dto "github.com/prometheus/client_model/go"
-	"github.com/prometheus/prom2json"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/thanhpk/randstr"
@@ -17,21 +14,21 @@ import (
 	"github.com/treeverse/lakefs/api/gen/client/repositories"
 	"github.com/treeverse/lakefs/api/gen/models"
 	"github.com/treeverse/lakefs/logging"
+	"github.com/treeverse/lakefs/pkg/retry"
+	"github.com/treeverse/lakefs/pkg/transfer"
 	"github.com/treeverse/lakefs/testutil"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"testing"
 	"time"
 )
 
 var (
-	logger logging.Logger
-	client *genclient.Lakefs
-	svc    *s3.S3
+	logger      logging.Logger
+	client      *genclient.Lakefs
+	svc         *s3.S3
+	retryPolicy retry.Config
 )
 
 func TestMain(m *testing.M) {
@@ -44,8 +41,23 @@ func TestMain(m *testing.M) {
 	viper.SetDefault("parallelism_level", 500)
 	viper.SetDefault("files_amount", 10000)
 	viper.SetDefault("global_timeout", 30*time.Minute)
+	viper.SetDefault("retry.tries", 3)
+	viper.SetDefault("retry.initial_backoff", 100*time.Millisecond)
+	viper.SetDefault("retry.max_backoff", 2*time.Second)
+	viper.SetDefault("retry.multiplier", 2.0)
+	viper.SetDefault("retry.max_elapsed_time", 10*time.Second)
+
+	retryPolicy = retry.Config{
+		Tries:          viper.GetInt("retry.tries"),
+		InitialBackoff: viper.GetDuration("retry.initial_backoff"),
+		MaxBackoff:     viper.GetDuration("retry.max_backoff"),
+		Multiplier:     viper.GetFloat64("retry.multiplier"),
+		MaxElapsedTime: viper.GetDuration("retry.max_elapsed_time"),
+		ShouldRetry:    retry.DefaultShouldRetry,
+	}
 
 	logger, client, svc = testutil.SetupTestingEnv("benchmark", "lakefs-benchmarking")
+	configureLogging()
 	logger.Info("Setup succeeded, running the tests")
 
 	if code := m.Run(); code != 0 {
@@ -61,39 +73,6 @@ var monitoredOps = map[string]bool{
 	"uploadObject": true,
 }
 
-func scrapePrometheus() {
-	lakefsEndpoint := viper.GetString("endpoint_url")
-	resp, err := http.DefaultClient.Get(lakefsEndpoint + "/metrics")
-	if err != nil {
-		panic(err)
-	}
-
-	ch := make(chan *dto.MetricFamily)
-	go func() { _ = prom2json.ParseResponse(resp, ch) }()
-	metrics := []*dto.Metric{}
-
-	for {
-		a, ok := <-ch
-		if !ok {
-			break
-		}
-
-		if *a.Name == "api_request_duration_seconds" {
-			for _, m := range a.Metric {
-				for _, label := range m.Label {
-					if *label.Name == "operation" && monitoredOps[*label.Value] {
-						metrics = append(metrics, m)
-					}
-				}
-			}
-		}
-	}
-
-	for _, m := range metrics {
-		fmt.Printf("%v\n", *m)
-	}
-}
-
 const (
 	contentSuffixLength = 32
 	//contentLength       = 128 * 1024
@@ -104,6 +83,76 @@ func TestBenchmarkLakeFS(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("global_timeout"))
 	defer cancel()
 
+	repoName := createBenchmarkRepository(ctx, t)
+
+	parallelism := viper.GetInt("parallelism_level")
+	filesAmount := viper.GetInt("files_amount")
+
+	contentPrefix := randstr.Hex(contentLength - contentSuffixLength)
+	failed := doInParallel(ctx, repoName, parallelism, filesAmount, uploadJob(contentPrefix))
+	logger.WithField("failedCount", failed).Info("Finished uploading files")
+
+	failed = doInParallel(ctx, repoName, parallelism, filesAmount, readJob)
+	logger.WithField("failedCount", failed).Info("Finished reading files")
+
+}
+
+// TestBenchmarkLakeFSWorkload drives a steady-state mixed workload (see
+// Profile) against a fresh repository for the configured workload.duration,
+// instead of the fixed upload-then-read phases of TestBenchmarkLakeFS.
+func TestBenchmarkLakeFSWorkload(t *testing.T) {
+	profile := LoadProfile()
+	ctx, cancel := context.WithTimeout(context.Background(), profile.Duration+viper.GetDuration("global_timeout"))
+	defer cancel()
+
+	repoName := createBenchmarkRepository(ctx, t)
+
+	parallelism := viper.GetInt("parallelism_level")
+	stats := RunWorkload(ctx, repoName, profile, parallelism)
+	logger.WithFields(logging.Fields{
+		"counts": stats.Counts,
+		"failed": stats.Failed,
+	}).Info("Finished workload run")
+}
+
+// TestBenchmarkLakeFSLargeObjects uploads large_objects_amount objects of
+// large_object_size bytes each, exercising the multipart upload path.
+func TestBenchmarkLakeFSLargeObjects(t *testing.T) {
+	viper.SetDefault("large_object_size", 256*1024*1024)
+	viper.SetDefault("large_objects_amount", 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("global_timeout"))
+	defer cancel()
+
+	repoName := createBenchmarkRepository(ctx, t)
+
+	objectSize := int64(viper.GetInt("large_object_size"))
+	objectsAmount := viper.GetInt("large_objects_amount")
+
+	var failed int
+	for i := 1; i <= objectsAmount; i++ {
+		key := strconv.Itoa(i)
+		content := bytes.NewReader([]byte(randstr.Hex(int(objectSize))))
+		latency, err := uploadLarge(ctx, repoName, key, content, objectSize)
+		if err != nil {
+			failed++
+			logger.WithFields(errorFields(err, logging.Fields{"fileNum": key})).WithError(err).Error("Failed uploading large object")
+			continue
+		}
+		throughput := float64(objectSize) / latency.Seconds()
+		logger.WithFields(logging.Fields{
+			"fileNum":            key,
+			"bytes":              objectSize,
+			"latency":            latency,
+			"throughputBytesSec": throughput,
+		}).Info("Uploaded large object")
+	}
+	logger.WithField("failedCount", failed).Info("Finished uploading large objects")
+}
+
+// createBenchmarkRepository creates a fresh lakeFS repository named after
+// the running test.
+func createBenchmarkRepository(ctx context.Context, t *testing.T) string {
 	ns := viper.GetString("storage_namespace")
 	repoName := strings.ToLower(t.Name())
 	logger.WithFields(logging.Fields{
@@ -118,119 +167,109 @@ func TestBenchmarkLakeFS(t *testing.T) {
 			StorageNamespace: swag.String(ns),
 		}), nil)
 	require.NoErrorf(t, err, "failed to create repository '%s', storage '%s'", t.Name(), ns)
-
-	parallelism := viper.GetInt("parallelism_level")
-	filesAmount := viper.GetInt("files_amount")
-
-	contentPrefix := randstr.Hex(contentLength - contentSuffixLength)
-	failed := doInParallel(ctx, repoName, parallelism, filesAmount, contentPrefix, uploader)
-	logger.WithField("failedCount", failed).Info("Finished uploading files")
-
-	failed = doInParallel(ctx, repoName, parallelism, filesAmount, "", reader)
-	logger.WithField("failedCount", failed).Info("Finished reading files")
-
+	return repoName
 }
 
-func doInParallel(ctx context.Context, repoName string, level, filesAmount int, contentPrefix string, do func(context.Context, chan string, string, string) int) int {
-	filesCh := make(chan string, level)
-	wg := sync.WaitGroup{}
-	var failed int64
-
-	for i := 0; i < level; i++ {
-		go func() {
-			wg.Add(1)
-			fail := do(ctx, filesCh, repoName, contentPrefix)
-			atomic.AddInt64(&failed, int64(fail))
-			wg.Done()
-		}()
-	}
-
-	for i := 1; i <= filesAmount; i++ {
-		filesCh <- strconv.Itoa(i)
-	}
-
-	close(filesCh)
-	wg.Wait()
+// doInParallel drives filesAmount jobs built by newJob against repoName
+// through a transfer.Manager bounded to level concurrent transfers. It
+// drains mgr.Events() for the lifetime of the run so retries logged via
+// onRetry also show up as EventRetried.
+func doInParallel(ctx context.Context, repoName string, level, filesAmount int, newJob func(mgr *transfer.Manager, repoName, file string) transfer.Job) int {
+	mgr := transfer.New(transfer.Config{Concurrency: level})
+	jobs := make(chan transfer.Job, level)
+
+	go func() {
+		defer close(jobs)
+		for i := 1; i <= filesAmount; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- newJob(mgr, repoName, strconv.Itoa(i)):
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for evt := range mgr.Events() {
+			if evt.Type == transfer.EventRetried {
+				logger.WithFields(logging.Fields{"key": evt.Key, "attempt": evt.Attempt}).WithError(evt.Err).Debug("Retrying transfer")
+			}
+		}
+	}()
 
-	return int(failed)
+	mgr.Run(ctx, jobs)
+	<-done
+	return int(mgr.Stats().Failed)
 }
 
-func uploader(ctx context.Context, ch chan string, repoName, contentPrefix string) int {
-	failed := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return failed
-		case file, ok := <-ch:
-			if !ok {
-				// channel closed
-				return failed
-			}
-
-			// Making sure content isn't duplicated to avoid dedup mechanisms in lakeFS
-			content := contentPrefix + randstr.Hex(contentSuffixLength)
-			contentReader := runtime.NamedReader("content", strings.NewReader(content))
+// uploadJob builds the transfer.Job that uploads a single benchmark file.
+func uploadJob(contentPrefix string) func(mgr *transfer.Manager, repoName, file string) transfer.Job {
+	return func(mgr *transfer.Manager, repoName, file string) transfer.Job {
+		key := "upload:" + file
+		return transfer.Job{
+			Key: key,
+			Do: func(ctx context.Context) (int64, error) {
+				content := contentPrefix + randstr.Hex(contentSuffixLength)
+
+				reqLogger := requestLogger(repoName, "uploadObject", file)
+				policy := retryPolicy
+				policy.OnRetry = func(attempt int, err error, backoff time.Duration) {
+					onRetry(reqLogger)(attempt, err, backoff)
+					mgr.NotifyRetry(key, attempt, err, backoff)
+				}
 
-			if err := linearRetry(func() error {
-				_, err := client.Objects.UploadObject(
-					objects.NewUploadObjectParamsWithContext(ctx).
-						WithRepository(repoName).
-						WithBranch("master").
-						WithPath(file).
-						WithContent(contentReader), nil)
-				return err
-			}); err != nil {
-				failed++
-				logger.WithField("fileNum", file).Error("Failed uploading file")
-			}
+				start := time.Now()
+				err := policy.Run(ctx, func(ctx context.Context) error {
+					contentReader := runtime.NamedReader("content", strings.NewReader(content))
+					_, err := client.Objects.UploadObject(
+						objects.NewUploadObjectParamsWithContext(ctx).
+							WithRepository(repoName).
+							WithBranch("master").
+							WithPath(file).
+							WithContent(contentReader), nil)
+					return err
+				})
+				if err != nil {
+					reqLogger.WithFields(errorFields(err, logging.Fields{"latency": time.Since(start)})).WithError(err).Error("Failed uploading file")
+					return 0, err
+				}
+				return int64(len(content)), nil
+			},
 		}
 	}
 }
 
-func reader(ctx context.Context, ch chan string, repoName, _ string) int {
-	failed := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return failed
-		case file, ok := <-ch:
-			if !ok {
-				// channel closed
-				return failed
+// readJob builds the transfer.Job that reads a single benchmark file back.
+func readJob(mgr *transfer.Manager, repoName, file string) transfer.Job {
+	key := "read:" + file
+	return transfer.Job{
+		Key: key,
+		Do: func(ctx context.Context) (int64, error) {
+			reqLogger := requestLogger(repoName, "getObject", file)
+			policy := retryPolicy
+			policy.OnRetry = func(attempt int, err error, backoff time.Duration) {
+				onRetry(reqLogger)(attempt, err, backoff)
+				mgr.NotifyRetry(key, attempt, err, backoff)
 			}
 
-			if err := linearRetry(func() error {
-				var b bytes.Buffer
+			start := time.Now()
+			var b bytes.Buffer
+			err := policy.Run(ctx, func(ctx context.Context) error {
+				b.Reset()
 				_, err := client.Objects.GetObject(
 					objects.NewGetObjectParamsWithContext(ctx).
 						WithRepository(repoName).
 						WithRef("master").
 						WithPath(file), nil, &b)
 				return err
-			}); err != nil {
-				failed++
-				logger.WithField("fileNum", file).Error("Failed reading file")
+			})
+			if err != nil {
+				reqLogger.WithFields(errorFields(err, logging.Fields{"latency": time.Since(start)})).WithError(err).Error("Failed reading file")
+				return 0, err
 			}
-		}
-	}
-}
-
-const (
-	tries        = 3
-	retryTimeout = 200 * time.Millisecond
-)
-
-func linearRetry(do func() error) error {
-	var err error
-	for i := 1; i <= tries; i++ {
-		if err = do(); err == nil {
-			return nil
-		}
-
-		if i != tries {
-			// skip sleep in the last iteration
-			time.Sleep(retryTimeout)
-		}
+			return int64(b.Len()), nil
+		},
 	}
-	return err
 }
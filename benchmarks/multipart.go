@@ -0,0 +1,247 @@
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/viper"
+	"github.com/treeverse/lakefs/api/gen/client/objects"
+	"github.com/treeverse/lakefs/api/gen/client/staging"
+	"github.com/treeverse/lakefs/api/gen/models"
+	"github.com/treeverse/lakefs/logging"
+	"github.com/treeverse/lakefs/pkg/transfer"
+)
+
+func init() {
+	viper.SetDefault("multipart_threshold", 8*1024*1024)
+	viper.SetDefault("multipart_part_size", 8*1024*1024)
+	viper.SetDefault("multipart_concurrency", 8)
+}
+
+// uploadLarge uploads content (size bytes) to repoName/master/key, choosing
+// between a single UploadObject call and a multipart upload based on the
+// multipart_threshold viper setting, and returns the end-to-end latency
+// alongside any error.
+func uploadLarge(ctx context.Context, repoName, key string, content io.ReaderAt, size int64) (time.Duration, error) {
+	threshold := int64(viper.GetInt("multipart_threshold"))
+	start := time.Now()
+
+	var err error
+	if size < threshold {
+		var body string
+		body, err = readAll(content, size)
+		if err == nil {
+			err = uploadOnce(ctx, repoName, key, body)
+		}
+	} else {
+		err = multipartUpload(ctx, repoName, key, content, size)
+	}
+	return time.Since(start), err
+}
+
+func readAll(r io.ReaderAt, size int64) (string, error) {
+	buf := make([]byte, size)
+	if _, err := r.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return "", fmt.Errorf("read content: %w", err)
+	}
+	return string(buf), nil
+}
+
+// multipartUpload splits content into multipart_part_size parts, uploads
+// them concurrently (bounded by multipart_concurrency) straight to the
+// physical address lakeFS staging hands back, and finalizes the object via
+// the staging/link API.
+func multipartUpload(ctx context.Context, repoName, key string, content io.ReaderAt, size int64) error {
+	partSize := int64(viper.GetInt("multipart_part_size"))
+	if partSize <= 0 {
+		partSize = size
+	}
+	concurrency := viper.GetInt("multipart_concurrency")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var stagingResp *staging.GetPhysicalAddressOK
+	if err := retryPolicy.Run(ctx, func(ctx context.Context) error {
+		var err error
+		stagingResp, err = client.Staging.GetPhysicalAddress(
+			staging.NewGetPhysicalAddressParamsWithContext(ctx).
+				WithRepository(repoName).
+				WithBranch("master").
+				WithPath(key), nil)
+		return err
+	}); err != nil {
+		return fmt.Errorf("get physical address for %q: %w", key, err)
+	}
+	loc := stagingResp.Payload
+	bucket, objectKey, ok := parseS3Path(loc.PhysicalAddress)
+	if !ok {
+		return fmt.Errorf("physical address %q is not an s3 location", loc.PhysicalAddress)
+	}
+
+	var createResp *s3.CreateMultipartUploadOutput
+	if err := retryPolicy.Run(ctx, func(ctx context.Context) error {
+		var err error
+		createResp, err = svc.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(objectKey),
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+	uploadID := createResp.UploadId
+
+	numParts := int((size + partSize - 1) / partSize)
+	parts := make([]*s3.CompletedPart, numParts)
+
+	partCtx, cancelParts := context.WithCancel(ctx)
+	defer cancelParts()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	recordErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancelParts()
+		}
+	}
+
+	mgr := transfer.New(transfer.Config{Concurrency: concurrency})
+	jobs := make(chan transfer.Job, numParts)
+	for i := 0; i < numParts; i++ {
+		partNum := i
+		offset := int64(i) * partSize
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		partKey := fmt.Sprintf("part:%d", partNum)
+		jobs <- transfer.Job{
+			Key: partKey,
+			Do: func(ctx context.Context) (int64, error) {
+				buf := make([]byte, length)
+				if _, err := content.ReadAt(buf, offset); err != nil && err != io.EOF {
+					err = fmt.Errorf("read part %d: %w", partNum, err)
+					recordErr(err)
+					return 0, err
+				}
+
+				var partResp *s3.UploadPartOutput
+				start := time.Now()
+				policy := retryPolicy
+				policy.OnRetry = func(attempt int, err error, backoff time.Duration) {
+					mgr.NotifyRetry(partKey, attempt, err, backoff)
+				}
+				err := policy.Run(ctx, func(ctx context.Context) error {
+					var err error
+					partResp, err = svc.UploadPartWithContext(ctx, &s3.UploadPartInput{
+						Bucket:        aws.String(bucket),
+						Key:           aws.String(objectKey),
+						UploadId:      uploadID,
+						PartNumber:    aws.Int64(int64(partNum + 1)),
+						Body:          bytes.NewReader(buf),
+						ContentLength: aws.Int64(length),
+					})
+					return err
+				})
+				logger.WithFields(logging.Fields{
+					"fileNum": key,
+					"part":    partNum + 1,
+					"bytes":   length,
+					"latency": time.Since(start),
+				}).Debug("Uploaded multipart part")
+				if err != nil {
+					err = fmt.Errorf("upload part %d: %w", partNum, err)
+					recordErr(err)
+					return 0, err
+				}
+
+				mu.Lock()
+				parts[partNum] = &s3.CompletedPart{
+					ETag:       partResp.ETag,
+					PartNumber: aws.Int64(int64(partNum + 1)),
+				}
+				mu.Unlock()
+				return length, nil
+			},
+		}
+	}
+	close(jobs)
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		for evt := range mgr.Events() {
+			if evt.Type == transfer.EventRetried {
+				logger.WithFields(logging.Fields{"fileNum": key, "part": evt.Key, "attempt": evt.Attempt}).WithError(evt.Err).Debug("Retrying multipart part")
+			}
+		}
+	}()
+	mgr.Run(partCtx, jobs)
+	<-eventsDone
+
+	if firstErr != nil {
+		_, _ = svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(objectKey),
+			UploadId: uploadID,
+		})
+		return firstErr
+	}
+
+	if err := retryPolicy.Run(ctx, func(ctx context.Context) error {
+		_, err := svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(objectKey),
+			UploadId: uploadID,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: parts,
+			},
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+
+	checksum := partsChecksum(parts)
+	if err := retryPolicy.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Objects.StageObject(
+			objects.NewStageObjectParamsWithContext(ctx).
+				WithRepository(repoName).
+				WithBranch("master").
+				WithPath(key).
+				WithObject(&models.ObjectStageCreation{
+					PhysicalAddress: loc.PhysicalAddress,
+					Checksum:        checksum,
+					SizeBytes:       size,
+				}), nil)
+		return err
+	}); err != nil {
+		return fmt.Errorf("link staged object %q: %w", key, err)
+	}
+	return nil
+}
+
+// partsChecksum derives a single checksum for the finished object from its
+// parts' ETags, analogous to how S3 computes a multipart ETag, so that two
+// uploads of identical content produce the same checksum.
+func partsChecksum(parts []*s3.CompletedPart) string {
+	h := md5.New()
+	for _, p := range parts {
+		_, _ = io.WriteString(h, aws.StringValue(p.ETag))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,216 @@
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prom2json"
+	"github.com/spf13/viper"
+	"github.com/treeverse/lakefs/logging"
+)
+
+// opQuantiles holds the estimated p50/p95/p99 latency, as time.Duration
+// values (JSON-encoded as nanoseconds), for a single monitored operation's
+// api_request_duration_seconds histogram. Sum is in seconds.
+type opQuantiles struct {
+	Operation string        `json:"operation"`
+	Count     uint64        `json:"count"`
+	Sum       float64       `json:"sum_seconds"`
+	P50       time.Duration `json:"p50"`
+	P95       time.Duration `json:"p95"`
+	P99       time.Duration `json:"p99"`
+}
+
+// snapshot is the JSON document written by pushSnapshot so benchmark runs
+// can be compared historically across CI builds.
+type snapshot struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Ops       []opQuantiles `json:"operations"`
+}
+
+// scrapePrometheus fetches api_request_duration_seconds from the lakeFS
+// /metrics endpoint, reconstructs a per-operation histogram, estimates
+// p50/p95/p99 via linear interpolation inside the matching bucket, and
+// fails the process if any monitored operation exceeds its configured SLO
+// threshold (thresholds.<operation>.p50/p95/p99, e.g.
+// thresholds.getObject.p99=0.5s). The scraped snapshot is also optionally
+// persisted so runs can be compared across CI builds.
+func scrapePrometheus() {
+	lakefsEndpoint := viper.GetString("endpoint_url")
+	resp, err := http.DefaultClient.Get(lakefsEndpoint + "/metrics")
+	if err != nil {
+		panic(err)
+	}
+
+	ch := make(chan *dto.MetricFamily)
+	go func() { _ = prom2json.ParseResponse(resp, ch) }()
+
+	var histograms []*dto.Metric
+	for a := range ch {
+		if a.GetName() != "api_request_duration_seconds" {
+			continue
+		}
+		for _, m := range a.Metric {
+			for _, label := range m.Label {
+				if label.GetName() == "operation" && monitoredOps[label.GetValue()] {
+					histograms = append(histograms, m)
+				}
+			}
+		}
+	}
+
+	snap := snapshot{Timestamp: time.Now(), Ops: make([]opQuantiles, 0, len(histograms))}
+
+	failed := false
+	for _, m := range histograms {
+		op := operationLabel(m)
+		q := quantilesFromHistogram(op, m.GetHistogram())
+		snap.Ops = append(snap.Ops, q)
+		logger.WithFields(logging.Fields{
+			"operation": op,
+			"p50":       q.P50,
+			"p95":       q.P95,
+			"p99":       q.P99,
+			"count":     q.Count,
+		}).Info("Scraped operation latency")
+
+		if exceedsThreshold(op, "p50", q.P50) || exceedsThreshold(op, "p95", q.P95) || exceedsThreshold(op, "p99", q.P99) {
+			failed = true
+		}
+	}
+
+	if dest := viper.GetString("snapshot.path"); dest != "" {
+		if err := pushSnapshot(snap, dest); err != nil {
+			logger.WithField("dest", dest).WithError(err).Error("Failed to push benchmark snapshot")
+		}
+	}
+
+	if failed {
+		logger.Error("One or more operations exceeded their SLO threshold")
+		os.Exit(1)
+	}
+}
+
+func operationLabel(m *dto.Metric) string {
+	for _, label := range m.Label {
+		if label.GetName() == "operation" {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// quantilesFromHistogram estimates p50/p95/p99 for a single Prometheus
+// histogram metric by linear interpolation inside the bucket that first
+// crosses the requested rank.
+func quantilesFromHistogram(op string, h *dto.Histogram) opQuantiles {
+	buckets := h.GetBucket()
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].GetUpperBound() < buckets[j].GetUpperBound() })
+
+	count := h.GetSampleCount()
+	return opQuantiles{
+		Operation: op,
+		Count:     count,
+		Sum:       h.GetSampleSum(),
+		P50:       interpolateQuantile(buckets, count, 0.50),
+		P95:       interpolateQuantile(buckets, count, 0.95),
+		P99:       interpolateQuantile(buckets, count, 0.99),
+	}
+}
+
+func interpolateQuantile(buckets []*dto.Bucket, totalCount uint64, rank float64) time.Duration {
+	if totalCount == 0 || len(buckets) == 0 {
+		return 0
+	}
+	target := rank * float64(totalCount)
+
+	var prevUpper, prevCount float64
+	for _, b := range buckets {
+		upper := b.GetUpperBound()
+		cumulative := float64(b.GetCumulativeCount())
+		if cumulative >= target {
+			if math.IsInf(upper, 1) {
+				// +Inf converts to a negative time.Duration, which
+				// would silently pass exceedsThreshold. Report it as
+				// the worst possible duration instead.
+				return time.Duration(math.MaxInt64)
+			}
+			bucketCount := cumulative - prevCount
+			if bucketCount <= 0 {
+				return time.Duration(upper * float64(time.Second))
+			}
+			fraction := (target - prevCount) / bucketCount
+			value := prevUpper + fraction*(upper-prevUpper)
+			return time.Duration(value * float64(time.Second))
+		}
+		prevUpper, prevCount = upper, cumulative
+	}
+	// rank falls beyond every finite bucket: report the highest bound seen.
+	return time.Duration(prevUpper * float64(time.Second))
+}
+
+// exceedsThreshold reports whether the measured latency for quantile (one
+// of "p50", "p95", "p99") of op is over its configured SLO, read from
+// thresholds.<op>.<quantile> (e.g. thresholds.getObject.p99=0.5s). Missing
+// thresholds are treated as unbounded.
+func exceedsThreshold(op, quantile string, measured time.Duration) bool {
+	key := fmt.Sprintf("thresholds.%s.%s", op, quantile)
+	if !viper.IsSet(key) {
+		return false
+	}
+	threshold := viper.GetDuration(key)
+	if measured > threshold {
+		logger.WithFields(logging.Fields{
+			"operation": op,
+			"quantile":  quantile,
+			"measured":  measured,
+			"threshold": threshold,
+		}).Error("Operation latency exceeded SLO threshold")
+		return true
+	}
+	return false
+}
+
+// pushSnapshot writes snap as JSON either to a local file path or, when
+// dest has an "s3://" scheme, to that S3 location via s3manager.
+func pushSnapshot(snap snapshot, dest string) error {
+	body, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	if bucket, key, ok := parseS3Path(dest); ok {
+		uploader := s3manager.NewUploaderWithClient(svc)
+		_, err := uploader.Upload(&s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(body),
+		})
+		return err
+	}
+
+	return os.WriteFile(dest, body, 0o644)
+}
+
+func parseS3Path(path string) (bucket, key string, ok bool) {
+	const prefix = "s3://"
+	if len(path) <= len(prefix) || path[:len(prefix)] != prefix {
+		return "", "", false
+	}
+	rest := path[len(prefix):]
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '/' {
+			return rest[:i], rest[i+1:], true
+		}
+	}
+	return rest, "", true
+}
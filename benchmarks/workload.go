@@ -0,0 +1,425 @@
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/spf13/viper"
+	"github.com/thanhpk/randstr"
+	"github.com/treeverse/lakefs/api/gen/client/branches"
+	"github.com/treeverse/lakefs/api/gen/client/commits"
+	"github.com/treeverse/lakefs/api/gen/client/objects"
+	"github.com/treeverse/lakefs/api/gen/client/refs"
+	"github.com/treeverse/lakefs/api/gen/models"
+	"github.com/treeverse/lakefs/logging"
+)
+
+// ObjectSizeDistribution names how generated object bodies are sized.
+type ObjectSizeDistribution string
+
+const (
+	SizeConstant  ObjectSizeDistribution = "constant"
+	SizeUniform   ObjectSizeDistribution = "uniform"
+	SizeLognormal ObjectSizeDistribution = "lognormal"
+	SizePareto    ObjectSizeDistribution = "pareto"
+)
+
+// KeyspaceModel names how an object key is picked out of the keyspace for a
+// given operation.
+type KeyspaceModel string
+
+const (
+	KeyspaceZipfian    KeyspaceModel = "zipfian"
+	KeyspaceUniform    KeyspaceModel = "uniform"
+	KeyspaceSequential KeyspaceModel = "sequential"
+)
+
+// Profile describes a steady-state workload: the mix of operations to
+// drive, how long to wait between them per worker, how big uploaded
+// objects should be, and how keys are picked out of the keyspace. It is
+// read from viper under the "workload" key, e.g.:
+//
+//	workload:
+//	  duration: 5m
+//	  think_time: 10ms
+//	  keyspace: {model: zipfian, cardinality: 100000}
+//	  object_size: {distribution: lognormal, min: 1024, max: 1048576}
+//	  mix: {upload: 0.2, getObject: 0.7, listObjects: 0.05, commit: 0.03, createBranch: 0.01, diff: 0.01}
+type Profile struct {
+	Duration    time.Duration
+	ThinkTime   time.Duration
+	Keyspace    KeyspaceModel
+	Cardinality int
+	ObjectSize  ObjectSizeDistribution
+	SizeMin     int
+	SizeMax     int
+	Mix         map[string]float64
+}
+
+// LoadProfile builds a Profile from viper, applying defaults for any mix
+// entry or distribution parameter that isn't set.
+func LoadProfile() Profile {
+	viper.SetDefault("workload.duration", time.Minute)
+	viper.SetDefault("workload.think_time", 0)
+	viper.SetDefault("workload.keyspace.model", string(KeyspaceUniform))
+	viper.SetDefault("workload.keyspace.cardinality", 10000)
+	viper.SetDefault("workload.object_size.distribution", string(SizeConstant))
+	viper.SetDefault("workload.object_size.min", contentLength)
+	viper.SetDefault("workload.object_size.max", contentLength)
+	viper.SetDefault("workload.mix.upload", 0.2)
+	viper.SetDefault("workload.mix.getObject", 0.7)
+	viper.SetDefault("workload.mix.listObjects", 0.05)
+	viper.SetDefault("workload.mix.commit", 0.03)
+	viper.SetDefault("workload.mix.createBranch", 0.01)
+	viper.SetDefault("workload.mix.diff", 0.01)
+
+	// viper lower-cases keys, so look each operation up by the name runOp
+	// switches on rather than ranging over the lower-cased map keys.
+	mix := make(map[string]float64)
+	for _, op := range []string{"upload", "getObject", "listObjects", "commit", "createBranch", "diff"} {
+		mix[op] = viper.GetFloat64("workload.mix." + op)
+	}
+
+	return Profile{
+		Duration:    viper.GetDuration("workload.duration"),
+		ThinkTime:   viper.GetDuration("workload.think_time"),
+		Keyspace:    KeyspaceModel(viper.GetString("workload.keyspace.model")),
+		Cardinality: viper.GetInt("workload.keyspace.cardinality"),
+		ObjectSize:  ObjectSizeDistribution(viper.GetString("workload.object_size.distribution")),
+		SizeMin:     viper.GetInt("workload.object_size.min"),
+		SizeMax:     viper.GetInt("workload.object_size.max"),
+		Mix:         mix,
+	}
+}
+
+// opPicker draws operation names from profile.Mix with probability
+// proportional to their configured weight.
+type opPicker struct {
+	names   []string
+	weights []float64
+	total   float64
+}
+
+func newOpPicker(mix map[string]float64) *opPicker {
+	p := &opPicker{}
+	for name, weight := range mix {
+		if weight <= 0 {
+			continue
+		}
+		p.names = append(p.names, name)
+		p.weights = append(p.weights, weight)
+		p.total += weight
+	}
+	// Deterministic iteration order makes runs reproducible given the
+	// same random seed.
+	sort.Sort(p)
+	return p
+}
+
+func (p *opPicker) Len() int           { return len(p.names) }
+func (p *opPicker) Swap(i, j int)      { p.names[i], p.names[j] = p.names[j], p.names[i]; p.weights[i], p.weights[j] = p.weights[j], p.weights[i] }
+func (p *opPicker) Less(i, j int) bool { return p.names[i] < p.names[j] }
+
+func (p *opPicker) pick(rnd *rand.Rand) string {
+	if len(p.names) == 0 {
+		return ""
+	}
+	target := rnd.Float64() * p.total
+	for i, w := range p.weights {
+		target -= w
+		if target <= 0 {
+			return p.names[i]
+		}
+	}
+	return p.names[len(p.names)-1]
+}
+
+// keyGenerator draws a key index out of [0, cardinality) according to a
+// KeyspaceModel.
+type keyGenerator func(rnd *rand.Rand) int
+
+func newKeyGenerator(model KeyspaceModel, cardinality int) keyGenerator {
+	if cardinality < 1 {
+		cardinality = 1
+	}
+	switch model {
+	case KeyspaceZipfian:
+		zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(cardinality-1))
+		var mu sync.Mutex
+		return func(_ *rand.Rand) int {
+			mu.Lock()
+			defer mu.Unlock()
+			return int(zipf.Uint64())
+		}
+	case KeyspaceSequential:
+		var next int64 = -1
+		return func(_ *rand.Rand) int {
+			n := atomic.AddInt64(&next, 1)
+			return int(n) % cardinality
+		}
+	case KeyspaceUniform:
+		fallthrough
+	default:
+		return func(rnd *rand.Rand) int { return rnd.Intn(cardinality) }
+	}
+}
+
+// sizeGenerator draws an object body size, in bytes, according to an
+// ObjectSizeDistribution bounded to [min, max].
+type sizeGenerator func(rnd *rand.Rand) int
+
+func newSizeGenerator(dist ObjectSizeDistribution, min, max int) sizeGenerator {
+	if max < min {
+		max = min
+	}
+	switch dist {
+	case SizeUniform:
+		return func(rnd *rand.Rand) int { return min + rnd.Intn(max-min+1) }
+	case SizeLognormal:
+		return func(rnd *rand.Rand) int {
+			mu := math.Log(float64(min+max) / 2)
+			v := math.Exp(mu + rnd.NormFloat64()*0.5)
+			return clamp(int(v), min, max)
+		}
+	case SizePareto:
+		return func(rnd *rand.Rand) int {
+			// Pareto with shape 2 and scale min, clamped to [min, max].
+			u := rnd.Float64()
+			v := float64(min) / math.Pow(1-u, 1.0/2.0)
+			return clamp(int(v), min, max)
+		}
+	case SizeConstant:
+		fallthrough
+	default:
+		return func(_ *rand.Rand) int { return min }
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// WorkloadStats aggregates counts of each operation driven during a
+// RunWorkload call, keyed by operation name.
+type WorkloadStats struct {
+	mu     sync.Mutex
+	Counts map[string]int
+	Failed map[string]int
+}
+
+func newWorkloadStats() *WorkloadStats {
+	return &WorkloadStats{Counts: map[string]int{}, Failed: map[string]int{}}
+}
+
+func (s *WorkloadStats) record(op string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Counts[op]++
+	if err != nil {
+		s.Failed[op]++
+	}
+}
+
+// RunWorkload drives parallelism workers against repoName according to
+// profile for profile.Duration (or until ctx is cancelled, whichever comes
+// first), picking an operation per iteration from profile.Mix and a key per
+// iteration from profile.Keyspace, producing a steady-state benchmark
+// instead of fixed upload/read phases.
+func RunWorkload(ctx context.Context, repoName string, profile Profile, parallelism int) *WorkloadStats {
+	ctx, cancel := context.WithTimeout(ctx, profile.Duration)
+	defer cancel()
+
+	stats := newWorkloadStats()
+	picker := newOpPicker(profile.Mix)
+	keyGen := newKeyGenerator(profile.Keyspace, profile.Cardinality)
+	sizeGen := newSizeGenerator(profile.ObjectSize, profile.SizeMin, profile.SizeMax)
+	branches := newBranchTracker()
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(workerID) + 1))
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+
+				op := picker.pick(rnd)
+				key := strconv.Itoa(keyGen(rnd))
+				err := runOp(ctx, repoName, op, key, sizeGen(rnd), rnd, branches)
+				stats.record(op, err)
+				if err != nil {
+					logger.WithFields(logging.Fields{"operation": op, "fileNum": key}).WithError(err).Warn("Workload operation failed")
+				}
+
+				if profile.ThinkTime > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(profile.ThinkTime):
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return stats
+}
+
+func runOp(ctx context.Context, repoName, op, key string, size int, rnd *rand.Rand, branches *branchTracker) error {
+	switch op {
+	case "upload":
+		content := randstr.Hex(size)
+		return uploadOnce(ctx, repoName, key, content)
+	case "getObject":
+		return readOnce(ctx, repoName, key)
+	case "listObjects":
+		return listObjectsOnce(ctx, repoName)
+	case "commit":
+		return commitOnce(ctx, repoName)
+	case "createBranch":
+		return createBranchOnce(ctx, repoName, branches)
+	case "diff":
+		return diffOnce(ctx, repoName, branches, rnd)
+	default:
+		return nil
+	}
+}
+
+func uploadOnce(ctx context.Context, repoName, key, content string) error {
+	policy := retryPolicy
+	return policy.Run(ctx, func(ctx context.Context) error {
+		contentReader := runtime.NamedReader("content", strings.NewReader(content))
+		_, err := client.Objects.UploadObject(
+			objects.NewUploadObjectParamsWithContext(ctx).
+				WithRepository(repoName).
+				WithBranch("master").
+				WithPath(key).
+				WithContent(contentReader), nil)
+		return err
+	})
+}
+
+func readOnce(ctx context.Context, repoName, key string) error {
+	policy := retryPolicy
+	return policy.Run(ctx, func(ctx context.Context) error {
+		var b bytes.Buffer
+		_, err := client.Objects.GetObject(
+			objects.NewGetObjectParamsWithContext(ctx).
+				WithRepository(repoName).
+				WithRef("master").
+				WithPath(key), nil, &b)
+		return err
+	})
+}
+
+func listObjectsOnce(ctx context.Context, repoName string) error {
+	policy := retryPolicy
+	return policy.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Objects.ListObjects(
+			objects.NewListObjectsParamsWithContext(ctx).
+				WithRepository(repoName).
+				WithRef("master"), nil)
+		return err
+	})
+}
+
+func commitOnce(ctx context.Context, repoName string) error {
+	policy := retryPolicy
+	return policy.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Commits.Commit(commits.NewCommitParamsWithContext(ctx).
+			WithRepository(repoName).
+			WithBranch("master").
+			WithCommit(&models.CommitCreation{Message: "benchmark commit"}), nil)
+		return err
+	})
+}
+
+// branchTracker hands out unique branch names for createBranchOnce and
+// remembers the ones that were actually created, so diffOnce has a real
+// branch to diff against master instead of a degenerate master-vs-master
+// no-op.
+type branchTracker struct {
+	mu      sync.Mutex
+	names   []string
+	counter int64
+}
+
+func newBranchTracker() *branchTracker {
+	return &branchTracker{}
+}
+
+func (t *branchTracker) nextName() string {
+	n := atomic.AddInt64(&t.counter, 1)
+	return "workload-" + strconv.FormatInt(n, 10)
+}
+
+func (t *branchTracker) add(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.names = append(t.names, name)
+}
+
+func (t *branchTracker) pick(rnd *rand.Rand) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.names) == 0 {
+		return "", false
+	}
+	return t.names[rnd.Intn(len(t.names))], true
+}
+
+func createBranchOnce(ctx context.Context, repoName string, tracked *branchTracker) error {
+	name := tracked.nextName()
+	policy := retryPolicy
+	if err := policy.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Branches.CreateBranch(branches.NewCreateBranchParamsWithContext(ctx).
+			WithRepository(repoName).
+			WithBranch(&models.BranchCreation{
+				Name:   name,
+				Source: "master",
+			}), nil)
+		return err
+	}); err != nil {
+		return err
+	}
+	tracked.add(name)
+	return nil
+}
+
+func diffOnce(ctx context.Context, repoName string, tracked *branchTracker, rnd *rand.Rand) error {
+	branch, ok := tracked.pick(rnd)
+	if !ok {
+		// No branch has been created yet: nothing to diff against master.
+		return nil
+	}
+
+	policy := retryPolicy
+	return policy.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Refs.DiffRefs(refs.NewDiffRefsParamsWithContext(ctx).
+			WithRepository(repoName).
+			WithLeftRef("master").
+			WithRightRef(branch), nil)
+		return err
+	})
+}
@@ -0,0 +1,164 @@
+// Package retry implements exponential backoff with full jitter and a
+// pluggable classification of retryable errors, for use by the benchmarks
+// package and any other caller driving the lakeFS generated client.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime"
+)
+
+// Config describes a retry policy: how many times to try, how backoff
+// grows between attempts, and which errors are worth retrying at all.
+type Config struct {
+	// Tries is the maximum number of attempts, including the first one.
+	Tries int
+	// InitialBackoff is the base delay used for the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay computed for any single retry.
+	MaxBackoff time.Duration
+	// Multiplier is the exponential growth factor applied per attempt,
+	// e.g. 2 doubles the backoff bound on every retry.
+	Multiplier float64
+	// MaxElapsedTime bounds the total time spent retrying, independent of
+	// Tries. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// ShouldRetry decides whether a failed attempt should be retried. A
+	// nil ShouldRetry defaults to DefaultShouldRetry.
+	ShouldRetry func(error) bool
+	// OnRetry, when set, is called after a failed attempt that will be
+	// retried, with the zero-indexed attempt number, the error that
+	// triggered the retry, and the backoff before the next attempt.
+	OnRetry func(attempt int, err error, backoff time.Duration)
+}
+
+// DefaultConfig returns a Config with sensible defaults: 3 tries, 100ms
+// initial backoff doubling up to 2s, and DefaultShouldRetry.
+func DefaultConfig() Config {
+	return Config{
+		Tries:          3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		ShouldRetry:    DefaultShouldRetry,
+	}
+}
+
+// StatusCode extracts the HTTP status code carried by err, if any: either a
+// *runtime.APIError from the go-openapi runtime or any error implementing
+// Code() int. ok is false for errors with no associated HTTP status (e.g. a
+// network error).
+func StatusCode(err error) (code int, ok bool) {
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code, true
+	}
+
+	type statusCoder interface {
+		Code() int
+	}
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return sc.Code(), true
+	}
+
+	return 0, false
+}
+
+// DefaultShouldRetry retries network errors and 429/5xx HTTP responses
+// surfaced by the go-openapi runtime, but not 4xx client errors: those
+// indicate a request that will never succeed no matter how many times it is
+// repeated.
+func DefaultShouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if code, ok := StatusCode(err); ok {
+		return shouldRetryStatus(code)
+	}
+
+	// Not an HTTP-classified error at all (e.g. a network error):
+	// retry it.
+	return true
+}
+
+func shouldRetryStatus(code int) bool {
+	if code == 0 {
+		return true
+	}
+	if code == http.StatusTooManyRequests {
+		return true
+	}
+	return code >= 500
+}
+
+// Run executes do, retrying according to cfg until it succeeds, cfg.Tries
+// is exhausted, cfg.MaxElapsedTime has passed, ctx is cancelled, or
+// cfg.ShouldRetry returns false for the latest error. It honors context
+// cancellation between attempts rather than sleeping through it.
+func (c Config) Run(ctx context.Context, do func(ctx context.Context) error) error {
+	shouldRetry := c.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = DefaultShouldRetry
+	}
+
+	start := time.Now()
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = do(ctx); err == nil {
+			return nil
+		}
+
+		isLastTry := c.Tries > 0 && attempt+1 >= c.Tries
+		if isLastTry || !shouldRetry(err) {
+			return err
+		}
+		if c.MaxElapsedTime > 0 && time.Since(start) >= c.MaxElapsedTime {
+			return err
+		}
+
+		backoff := c.backoff(attempt)
+		if c.OnRetry != nil {
+			c.OnRetry(attempt, err, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoff computes a full-jitter exponential delay for the given attempt
+// (0-indexed): random(0, min(MaxBackoff, InitialBackoff * Multiplier^attempt)).
+func (c Config) backoff(attempt int) time.Duration {
+	multiplier := c.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	cap := float64(c.MaxBackoff)
+	if cap <= 0 {
+		cap = float64(c.InitialBackoff)
+	}
+
+	delay := float64(c.InitialBackoff) * math.Pow(multiplier, float64(attempt))
+	if delay > cap {
+		delay = cap
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
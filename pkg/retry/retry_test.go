@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/runtime"
+)
+
+type statusErr struct{ code int }
+
+func (e statusErr) Error() string { return fmt.Sprintf("status %d", e.code) }
+func (e statusErr) Code() int     { return e.code }
+
+func TestStatusCode(t *testing.T) {
+	if code, ok := StatusCode(errors.New("network error")); ok {
+		t.Errorf("StatusCode(unclassified) = (%d, %v), want ok=false", code, ok)
+	}
+	if code, ok := StatusCode(&runtime.APIError{Code: http.StatusNotFound}); !ok || code != http.StatusNotFound {
+		t.Errorf("StatusCode(APIError) = (%d, %v), want (%d, true)", code, ok, http.StatusNotFound)
+	}
+	if code, ok := StatusCode(statusErr{http.StatusBadRequest}); !ok || code != http.StatusBadRequest {
+		t.Errorf("StatusCode(statusCoder) = (%d, %v), want (%d, true)", code, ok, http.StatusBadRequest)
+	}
+}
+
+func TestDefaultShouldRetry(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"context canceled", context.Canceled, false},
+		{"context deadline exceeded", context.DeadlineExceeded, false},
+		{"wrapped context canceled", fmt.Errorf("do: %w", context.Canceled), false},
+		{"api error 429", &runtime.APIError{Code: http.StatusTooManyRequests}, true},
+		{"api error 500", &runtime.APIError{Code: http.StatusInternalServerError}, true},
+		{"api error 404", &runtime.APIError{Code: http.StatusNotFound}, false},
+		{"status coder 503", statusErr{http.StatusServiceUnavailable}, true},
+		{"status coder 400", statusErr{http.StatusBadRequest}, false},
+		{"unclassified network error", errors.New("connection reset"), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := DefaultShouldRetry(c.err); got != c.want {
+				t.Errorf("DefaultShouldRetry(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestConfigBackoff(t *testing.T) {
+	c := Config{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, want := 0, 100*time.Millisecond; attempt < 10; attempt++ {
+		if d := c.backoff(attempt); d > want && want < c.MaxBackoff {
+			t.Errorf("backoff(%d) = %v, want <= %v", attempt, d, want)
+		}
+		if d := c.backoff(attempt); d > c.MaxBackoff {
+			t.Errorf("backoff(%d) = %v exceeds MaxBackoff %v", attempt, d, c.MaxBackoff)
+		}
+		want *= 2
+	}
+}
+
+func TestConfigRunRetriesUntilSuccess(t *testing.T) {
+	attempts := 0
+	c := Config{Tries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2, ShouldRetry: DefaultShouldRetry}
+	err := c.Run(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestConfigRunStopsOnNonRetryableError(t *testing.T) {
+	attempts := 0
+	c := Config{Tries: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2, ShouldRetry: DefaultShouldRetry}
+	wantErr := &runtime.APIError{Code: http.StatusNotFound}
+	err := c.Run(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return wantErr
+	})
+	if !errors.Is(err, error(wantErr)) && err != wantErr {
+		t.Fatalf("Run() = %v, want %v", err, wantErr)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry on non-retryable error)", attempts)
+	}
+}
+
+func TestConfigRunHonorsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := Config{Tries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2, ShouldRetry: DefaultShouldRetry}
+	attempts := 0
+	err := c.Run(ctx, func(ctx context.Context) error {
+		attempts++
+		return errors.New("transient")
+	})
+	if err == nil {
+		t.Fatal("Run() = nil, want an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+}
@@ -0,0 +1,245 @@
+// Package transfer provides a reusable, bounded-concurrency transfer manager
+// for moving objects to and from lakeFS. It is modeled on Docker's
+// distribution upload/download manager: callers submit keyed Jobs, the
+// Manager schedules them across a fixed worker pool, de-duplicates
+// in-flight work by key, and reports progress as the transfers run.
+//
+// The benchmarks package and bulk upload/download tooling built on top of
+// client.Objects are both expected to use this package instead of rolling
+// their own worker pools.
+package transfer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to a Job.
+type EventType int
+
+const (
+	// EventCompleted reports that a Job finished successfully.
+	EventCompleted EventType = iota
+	// EventFailed reports that a Job's Do returned an error.
+	EventFailed
+	// EventRetried reports that a Job's underlying retry policy is
+	// retrying a failed attempt. Jobs that retry internally (e.g. via
+	// retry.Config.OnRetry) should call Manager.NotifyRetry so this shows
+	// up alongside EventCompleted/EventFailed.
+	EventRetried
+)
+
+// Event is emitted on Manager.Events() when a Job completes, fails or is
+// retried, unless the channel is full (see Manager.Events).
+type Event struct {
+	Type     EventType
+	Key      string
+	Attempt  int
+	Bytes    int64
+	Err      error
+	Duration time.Duration
+}
+
+// Job is a unit of work submitted to the Manager. Do is invoked with a
+// context that is cancelled when the Manager's Run context is done. Key
+// identifies the job for de-duplication: a second Job submitted with a key
+// that is already in flight is dropped; its Do is never called and no Event
+// or Stats entry is recorded for it.
+type Job struct {
+	Key string
+	Do  func(ctx context.Context) (bytesMoved int64, err error)
+}
+
+// Config configures a Manager.
+type Config struct {
+	// Concurrency bounds the number of Jobs executed at the same time.
+	Concurrency int
+}
+
+// Manager schedules Jobs against a bounded pool of workers, de-duplicates
+// in-flight work by key and reports progress events as Jobs complete.
+type Manager struct {
+	cfg    Config
+	events chan Event
+
+	inFlightMu sync.Mutex
+	inFlight   map[string]struct{}
+
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// New returns a Manager ready to Run Jobs. cfg.Concurrency is clamped to at
+// least 1.
+func New(cfg Config) *Manager {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	return &Manager{
+		cfg:      cfg,
+		events:   make(chan Event, cfg.Concurrency),
+		inFlight: make(map[string]struct{}),
+	}
+}
+
+// Events returns the channel completion, failure and retry Events are
+// emitted on. Workers never block on it: an Event that does not fit in the
+// channel's buffer is dropped, so callers should drain it for the lifetime
+// of a Run call to see every Event.
+func (m *Manager) Events() <-chan Event {
+	return m.events
+}
+
+// NotifyRetry emits an EventRetried for key. Jobs whose Do retries
+// internally (e.g. by setting retry.Config.OnRetry) should call this from
+// that callback so retries are visible on Events() alongside completion and
+// failure.
+func (m *Manager) NotifyRetry(key string, attempt int, err error, backoff time.Duration) {
+	m.emit(Event{Type: EventRetried, Key: key, Attempt: attempt, Err: err, Duration: backoff})
+}
+
+// Run schedules every Job in jobs across the Manager's worker pool and
+// blocks until they have all completed, failed or ctx was cancelled.
+// Duplicate keys are skipped after the first occurrence. Run closes the
+// Manager's event channel before returning, so it must not be called more
+// than once on the same Manager.
+func (m *Manager) Run(ctx context.Context, jobs <-chan Job) {
+	defer close(m.events)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.cfg.Concurrency)
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case job, ok := <-jobs:
+			if !ok {
+				wg.Wait()
+				return
+			}
+			if m.markInFlight(job.Key) {
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				m.clearInFlight(job.Key)
+				wg.Wait()
+				return
+			}
+
+			wg.Add(1)
+			go func(job Job) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer m.clearInFlight(job.Key)
+				m.execute(ctx, job)
+			}(job)
+		}
+	}
+}
+
+func (m *Manager) markInFlight(key string) (alreadyInFlight bool) {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	if _, ok := m.inFlight[key]; ok {
+		return true
+	}
+	m.inFlight[key] = struct{}{}
+	return false
+}
+
+func (m *Manager) clearInFlight(key string) {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	delete(m.inFlight, key)
+}
+
+func (m *Manager) execute(ctx context.Context, job Job) {
+	start := time.Now()
+	bytesMoved, err := job.Do(ctx)
+	duration := time.Since(start)
+
+	m.recordLatency(duration)
+	if err != nil {
+		m.statsMu.Lock()
+		m.stats.Failed++
+		m.statsMu.Unlock()
+		m.emit(Event{Type: EventFailed, Key: job.Key, Err: err, Duration: duration})
+		return
+	}
+
+	m.statsMu.Lock()
+	m.stats.Completed++
+	m.stats.BytesMoved += bytesMoved
+	m.statsMu.Unlock()
+	m.emit(Event{Type: EventCompleted, Key: job.Key, Bytes: bytesMoved, Duration: duration})
+}
+
+func (m *Manager) emit(evt Event) {
+	select {
+	case m.events <- evt:
+	default:
+		// Slow or absent consumer: drop the event rather than block a
+		// worker. Aggregate Stats are still tracked accurately.
+	}
+}
+
+func (m *Manager) recordLatency(d time.Duration) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	m.stats.latencies = append(m.stats.latencies, d)
+}
+
+// Stats holds aggregate counters and latency percentiles for the Jobs a
+// Manager has run so far. Stats is safe to read via Manager.Stats once Run
+// has returned.
+type Stats struct {
+	Completed  int64
+	Failed     int64
+	BytesMoved int64
+
+	latencies []time.Duration
+}
+
+// Stats returns a snapshot of the aggregate counters collected during Run.
+// The snapshot carries no latencies; use Percentiles for p50/p95/p99.
+func (m *Manager) Stats() Stats {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	snapshot := Stats{
+		Completed:  m.stats.Completed,
+		Failed:     m.stats.Failed,
+		BytesMoved: m.stats.BytesMoved,
+	}
+	return snapshot
+}
+
+// Percentiles returns the p50, p95 and p99 latencies across every Job the
+// Manager has executed so far.
+func (m *Manager) Percentiles() (p50, p95, p99 time.Duration) {
+	m.statsMu.Lock()
+	latencies := make([]time.Duration, len(m.stats.latencies))
+	copy(latencies, m.stats.latencies)
+	m.statsMu.Unlock()
+
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return percentile(latencies, 0.50), percentile(latencies, 0.95), percentile(latencies, 0.99)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
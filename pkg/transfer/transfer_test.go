@@ -0,0 +1,168 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func drainJobs(jobs chan<- Job, items []Job) {
+	defer close(jobs)
+	for _, j := range items {
+		jobs <- j
+	}
+}
+
+func TestManagerRunRespectsConcurrency(t *testing.T) {
+	const concurrency = 2
+	m := New(Config{Concurrency: concurrency})
+
+	var inFlight, maxInFlight int64
+	jobs := make(chan Job)
+	items := make([]Job, 10)
+	for i := range items {
+		items[i] = Job{
+			Key: string(rune('a' + i)),
+			Do: func(ctx context.Context) (int64, error) {
+				n := atomic.AddInt64(&inFlight, 1)
+				for {
+					max := atomic.LoadInt64(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&inFlight, -1)
+				return 1, nil
+			},
+		}
+	}
+	go drainJobs(jobs, items)
+
+	m.Run(context.Background(), jobs)
+
+	if maxInFlight > concurrency {
+		t.Errorf("observed %d jobs in flight, want at most %d", maxInFlight, concurrency)
+	}
+	stats := m.Stats()
+	if stats.Completed != int64(len(items)) {
+		t.Errorf("Completed = %d, want %d", stats.Completed, len(items))
+	}
+}
+
+func TestManagerRunDeduplicatesInFlightKeys(t *testing.T) {
+	m := New(Config{Concurrency: 4})
+
+	var calls int64
+	jobs := make(chan Job, 2)
+	jobs <- Job{Key: "dup", Do: func(ctx context.Context) (int64, error) {
+		atomic.AddInt64(&calls, 1)
+		return 0, nil
+	}}
+	jobs <- Job{Key: "dup", Do: func(ctx context.Context) (int64, error) {
+		atomic.AddInt64(&calls, 1)
+		return 0, nil
+	}}
+	close(jobs)
+
+	m.Run(context.Background(), jobs)
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (second job with the same key should be skipped)", calls)
+	}
+}
+
+func TestManagerStatsTracksFailures(t *testing.T) {
+	m := New(Config{Concurrency: 1})
+
+	wantErr := errors.New("boom")
+	jobs := make(chan Job, 1)
+	jobs <- Job{Key: "a", Do: func(ctx context.Context) (int64, error) {
+		return 0, wantErr
+	}}
+	close(jobs)
+
+	m.Run(context.Background(), jobs)
+
+	stats := m.Stats()
+	if stats.Failed != 1 || stats.Completed != 0 {
+		t.Errorf("Stats() = %+v, want Failed=1 Completed=0", stats)
+	}
+}
+
+func TestManagerRunStopsOnContextCancellation(t *testing.T) {
+	m := New(Config{Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	jobs := make(chan Job, 1)
+	jobs <- Job{Key: "a", Do: func(ctx context.Context) (int64, error) {
+		return 1, nil
+	}}
+	close(jobs)
+
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, jobs)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return promptly after context cancellation")
+	}
+}
+
+func TestManagerNotifyRetryEmitsEvent(t *testing.T) {
+	m := New(Config{Concurrency: 1})
+
+	jobs := make(chan Job, 1)
+	jobs <- Job{Key: "a", Do: func(ctx context.Context) (int64, error) {
+		m.NotifyRetry("a", 0, errors.New("transient"), time.Millisecond)
+		return 0, nil
+	}}
+	close(jobs)
+
+	var events []Event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for evt := range m.Events() {
+			events = append(events, evt)
+		}
+	}()
+
+	m.Run(context.Background(), jobs)
+	<-done
+
+	var sawRetry bool
+	for _, evt := range events {
+		if evt.Type == EventRetried && evt.Key == "a" {
+			sawRetry = true
+		}
+	}
+	if !sawRetry {
+		t.Errorf("events = %+v, want an EventRetried for key %q", events, "a")
+	}
+}
+
+func TestManagerPercentiles(t *testing.T) {
+	m := New(Config{Concurrency: 1})
+
+	jobs := make(chan Job, 3)
+	jobs <- Job{Key: "a", Do: func(ctx context.Context) (int64, error) { return 0, nil }}
+	jobs <- Job{Key: "b", Do: func(ctx context.Context) (int64, error) { return 0, nil }}
+	jobs <- Job{Key: "c", Do: func(ctx context.Context) (int64, error) { return 0, nil }}
+	close(jobs)
+
+	m.Run(context.Background(), jobs)
+
+	p50, p95, p99 := m.Percentiles()
+	if p50 < 0 || p95 < 0 || p99 < 0 {
+		t.Errorf("Percentiles() = %v %v %v, want non-negative", p50, p95, p99)
+	}
+}